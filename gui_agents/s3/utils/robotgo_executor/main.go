@@ -1,15 +1,26 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"math/rand"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-vgo/robotgo"
+	hook "github.com/robotn/gohook"
+	bitmapi "github.com/vcaesar/bitmap"
 )
 
 // Action represents a GUI action command
@@ -20,6 +31,132 @@ type Action struct {
 	Platform string                 `json:"platform,omitempty"`
 }
 
+// ActionResult is the structured response written for every executed action,
+// whether it runs through the one-shot CLI path or the daemon's stdin loop.
+type ActionResult struct {
+	OK     bool        `json:"ok"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// BatchRequest is the top-level shape accepted by daemon mode in addition to
+// a bare Action: a sequence of actions run one after another.
+type BatchRequest struct {
+	Batch   []Action `json:"batch"`
+	DelayMs int      `json:"delay_ms,omitempty"`
+	OnError string   `json:"on_error,omitempty"` // "abort" (default) or "continue"
+}
+
+// actionHandler executes a single action and returns its result payload.
+type actionHandler func(action Action, platform string) (interface{}, error)
+
+// actionRegistry maps action types to their handlers. Populated by init() so
+// daemon mode and the one-shot CLI path share identical dispatch.
+var actionRegistry = map[string]actionHandler{}
+
+func init() {
+	actionRegistry["click"] = handleClick
+	actionRegistry["moveTo"] = handleMoveTo
+	actionRegistry["dragTo"] = handleDragTo
+	actionRegistry["type"] = handleType
+	actionRegistry["write"] = handleType
+	actionRegistry["press"] = handlePress
+	actionRegistry["hotkey"] = handleHotkey
+	actionRegistry["keyDown"] = handleKeyDown
+	actionRegistry["keyUp"] = handleKeyUp
+	actionRegistry["scroll"] = handleScroll
+	actionRegistry["wait"] = handleWait
+	actionRegistry["screenSize"] = handleScreenSize
+	actionRegistry["record"] = handleRecord
+	actionRegistry["replay"] = handleReplay
+	actionRegistry["screenshot"] = handleScreenshot
+	actionRegistry["pixelColor"] = handlePixelColor
+	actionRegistry["waitFor"] = handleWaitFor
+	actionRegistry["displays"] = handleDisplays
+}
+
+// displayScale returns the logical-to-physical pixel scale factor for
+// display index on the current platform. Only macOS Retina displays report
+// a scale other than 1 through robotgo.ScaleF(index); other platforms
+// already work in physical pixels. robotgo.ScaleF takes the display index
+// so a Retina panel and a non-Retina external monitor report their own
+// scale rather than sharing whichever display ScaleF defaults to.
+func displayScale(platform string, index int) float64 {
+	if platform == "darwin" {
+		return robotgo.ScaleF(index)
+	}
+	return 1.0
+}
+
+// resolveCoord translates an action's x,y into absolute physical screen
+// pixels according to its optional "display" (monitor index) and
+// "coord_space" ("physical" [default], "logical", or "normalized" in
+// [0,1]) params. With neither param set it returns x,y unchanged, so
+// existing callers that pass raw physical coordinates keep working exactly
+// as before.
+func resolveCoord(params map[string]interface{}, x, y float64, platform string) (float64, float64) {
+	coordSpace, _ := params["coord_space"].(string)
+	displayIndex := 0
+	hasDisplay := false
+	if d, err := getFloat(params["display"]); err == nil {
+		displayIndex = int(d)
+		hasDisplay = true
+	}
+
+	originX, originY, dispW, dispH := 0, 0, 0, 0
+	if hasDisplay || coordSpace == "normalized" {
+		rect := robotgo.GetScreenRect(displayIndex)
+		originX, originY, dispW, dispH = rect.X, rect.Y, rect.W, rect.H
+	}
+
+	switch coordSpace {
+	case "normalized":
+		if dispW == 0 {
+			dispW, dispH = robotgo.GetScreenSize()
+		}
+		return float64(originX) + x*float64(dispW), float64(originY) + y*float64(dispH)
+	case "logical":
+		scale := displayScale(platform, displayIndex)
+		return float64(originX) + x*scale, float64(originY) + y*scale
+	default: // "physical" or unspecified
+		if hasDisplay {
+			return float64(originX) + x, float64(originY) + y
+		}
+		return x, y
+	}
+}
+
+// resolveExtent scales a width/height by the same factor resolveCoord
+// applies to x,y for the given "coord_space"/"display" params. Unlike
+// resolveCoord it never applies the display's origin offset, since a
+// width/height is a delta, not a position.
+func resolveExtent(params map[string]interface{}, w, h float64, platform string) (float64, float64) {
+	coordSpace, _ := params["coord_space"].(string)
+	displayIndex := 0
+	if d, err := getFloat(params["display"]); err == nil {
+		displayIndex = int(d)
+	}
+
+	switch coordSpace {
+	case "normalized":
+		rect := robotgo.GetScreenRect(displayIndex)
+		dispW, dispH := rect.W, rect.H
+		if dispW == 0 {
+			dispW, dispH = robotgo.GetScreenSize()
+		}
+		return w * float64(dispW), h * float64(dispH)
+	case "logical":
+		scale := displayScale(platform, displayIndex)
+		return w * scale, h * scale
+	default: // "physical" or unspecified
+		return w, h
+	}
+}
+
+// defaultAbortHotkey is the key combination that stops an in-progress
+// recording or replay.
+var defaultAbortHotkey = []string{"q", "shift", "ctrl"}
+
 // normalizeKey normalizes key names for the current platform
 func normalizeKey(key string, platform string) string {
 	keyLower := strings.ToLower(key)
@@ -62,224 +199,926 @@ func normalizeKey(key string, platform string) string {
 	return key
 }
 
-// executeAction executes a GUI action using robotgo
-func executeAction(action Action) error {
-	platform := action.Platform
-	if platform == "" {
-		platform = runtime.GOOS
+func handleClick(action Action, platform string) (interface{}, error) {
+	x, _ := getFloat(action.Params["x"])
+	y, _ := getFloat(action.Params["y"])
+	if x < 0 || y < 0 {
+		return nil, fmt.Errorf("invalid coordinates: x=%v, y=%v", x, y)
 	}
-
-	switch action.Type {
-	case "click":
-		x, _ := getFloat(action.Params["x"])
-		y, _ := getFloat(action.Params["y"])
-		if x < 0 || y < 0 {
-			return fmt.Errorf("invalid coordinates: x=%v, y=%v", x, y)
-		}
-		clicks := 1
-		if c, ok := action.Params["clicks"]; ok {
-			if ci, ok := c.(float64); ok {
-				clicks = int(ci)
-			} else if ci, ok := c.(int); ok {
-				clicks = ci
-			}
-		}
-		button := "left"
-		if b, ok := action.Params["button"]; ok {
-			button = fmt.Sprintf("%v", b)
+	x, y = resolveCoord(action.Params, x, y, platform)
+	clicks := 1
+	if c, ok := action.Params["clicks"]; ok {
+		if ci, ok := c.(float64); ok {
+			clicks = int(ci)
+		} else if ci, ok := c.(int); ok {
+			clicks = ci
 		}
+	}
+	button := "left"
+	if b, ok := action.Params["button"]; ok {
+		button = fmt.Sprintf("%v", b)
+	}
 
-		// Hold modifier keys if specified
-		if holdKeys, ok := action.Params["hold_keys"]; ok {
-			if keys, ok := holdKeys.([]interface{}); ok {
-				for _, k := range keys {
-					key := normalizeKey(fmt.Sprintf("%v", k), platform)
-					robotgo.KeyToggle(key, "down")
-				}
+	// Hold modifier keys if specified
+	if holdKeys, ok := action.Params["hold_keys"]; ok {
+		if keys, ok := holdKeys.([]interface{}); ok {
+			for _, k := range keys {
+				key := normalizeKey(fmt.Sprintf("%v", k), platform)
+				robotgo.KeyToggle(key, "down")
 			}
 		}
+	}
 
-		// Move to position first (like turing does)
-		robotgo.Move(int(x), int(y))
-		robotgo.MilliSleep(100) // Small delay to ensure movement completes
+	// Move to position first (like turing does), optionally interpolating
+	// the motion for a more human-like cursor path.
+	fromX, fromY := robotgo.GetMousePos()
+	moveSmooth(float64(fromX), float64(fromY), x, y, parseMotion(action.Params))
+	robotgo.MilliSleep(100) // Small delay to ensure movement completes
 
-		// Perform click(s) - use double click for clicks > 1
-		if clicks == 1 {
-			if button == "right" {
-				robotgo.Click("right")
-			} else {
-				robotgo.Click()
-			}
-		} else if clicks == 2 {
-			robotgo.Click("left", true) // double click
+	// Perform click(s) - use double click for clicks > 1
+	if clicks == 1 {
+		if button == "right" {
+			robotgo.Click("right")
 		} else {
-			// For more clicks, do multiple single clicks
-			for i := 0; i < clicks; i++ {
-				robotgo.Click()
-				if i < clicks-1 {
-					robotgo.MilliSleep(50)
-				}
+			robotgo.Click()
+		}
+	} else if clicks == 2 {
+		robotgo.Click("left", true) // double click
+	} else {
+		// For more clicks, do multiple single clicks
+		for i := 0; i < clicks; i++ {
+			robotgo.Click()
+			if i < clicks-1 {
+				robotgo.MilliSleep(50)
 			}
 		}
+	}
 
-		// Release modifier keys
-		if holdKeys, ok := action.Params["hold_keys"]; ok {
-			if keys, ok := holdKeys.([]interface{}); ok {
-				for _, k := range keys {
-					key := normalizeKey(fmt.Sprintf("%v", k), platform)
-					robotgo.KeyToggle(key, "up")
-				}
+	// Release modifier keys
+	if holdKeys, ok := action.Params["hold_keys"]; ok {
+		if keys, ok := holdKeys.([]interface{}); ok {
+			for _, k := range keys {
+				key := normalizeKey(fmt.Sprintf("%v", k), platform)
+				robotgo.KeyToggle(key, "up")
 			}
 		}
+	}
 
-	case "moveTo":
-		x, _ := getFloat(action.Params["x"])
-		y, _ := getFloat(action.Params["y"])
-		if x < 0 || y < 0 {
-			return fmt.Errorf("invalid coordinates: x=%v, y=%v", x, y)
-		}
-		robotgo.Move(int(x), int(y))
+	return nil, nil
+}
 
-	case "dragTo":
-		x1, _ := getFloat(action.Params["x1"])
-		y1, _ := getFloat(action.Params["y1"])
-		x2, _ := getFloat(action.Params["x2"])
-		y2, _ := getFloat(action.Params["y2"])
-		if x1 < 0 || y1 < 0 || x2 < 0 || y2 < 0 {
-			return fmt.Errorf("invalid drag coordinates: (%v,%v) to (%v,%v)", x1, y1, x2, y2)
-		}
-		// button parameter currently unused by robotgo.Drag
-		// button := "left"
-		// if b, ok := action.Params["button"]; ok {
-		// 	button = fmt.Sprintf("%v", b)
-		// }
-
-		// Hold modifier keys if specified
-		if holdKeys, ok := action.Params["hold_keys"]; ok {
-			if keys, ok := holdKeys.([]interface{}); ok {
-				for _, k := range keys {
-					key := normalizeKey(fmt.Sprintf("%v", k), platform)
-					robotgo.KeyToggle(key, "down")
-				}
+func handleMoveTo(action Action, platform string) (interface{}, error) {
+	x, _ := getFloat(action.Params["x"])
+	y, _ := getFloat(action.Params["y"])
+	if x < 0 || y < 0 {
+		return nil, fmt.Errorf("invalid coordinates: x=%v, y=%v", x, y)
+	}
+	x, y = resolveCoord(action.Params, x, y, platform)
+	fromX, fromY := robotgo.GetMousePos()
+	moveSmooth(float64(fromX), float64(fromY), x, y, parseMotion(action.Params))
+	return nil, nil
+}
+
+func handleDragTo(action Action, platform string) (interface{}, error) {
+	x1, _ := getFloat(action.Params["x1"])
+	y1, _ := getFloat(action.Params["y1"])
+	x2, _ := getFloat(action.Params["x2"])
+	y2, _ := getFloat(action.Params["y2"])
+	if x1 < 0 || y1 < 0 || x2 < 0 || y2 < 0 {
+		return nil, fmt.Errorf("invalid drag coordinates: (%v,%v) to (%v,%v)", x1, y1, x2, y2)
+	}
+	x1, y1 = resolveCoord(action.Params, x1, y1, platform)
+	x2, y2 = resolveCoord(action.Params, x2, y2, platform)
+	button := "left"
+	if b, ok := action.Params["button"]; ok {
+		button = fmt.Sprintf("%v", b)
+	}
+
+	// Hold modifier keys if specified
+	if holdKeys, ok := action.Params["hold_keys"]; ok {
+		if keys, ok := holdKeys.([]interface{}); ok {
+			for _, k := range keys {
+				key := normalizeKey(fmt.Sprintf("%v", k), platform)
+				robotgo.KeyToggle(key, "down")
 			}
 		}
+	}
 
-		// Move to start position first (robotgo.Drag drags from current position)
-		robotgo.Move(int(x1), int(y1))
-		robotgo.MilliSleep(100)
+	// Move to start position first (robotgo.Drag drags from current position)
+	robotgo.Move(int(x1), int(y1))
+	robotgo.MilliSleep(100)
 
+	if motion := parseMotion(action.Params); motion != nil {
+		// Hold the button through an interpolated path instead of
+		// teleporting via robotgo.Drag.
+		robotgo.Toggle(button, "down")
+		moveSmooth(x1, y1, x2, y2, motion)
+		robotgo.Toggle(button, "up")
+	} else {
 		// Drag to end position (robotgo.Drag takes absolute coordinates)
 		robotgo.Drag(int(x2), int(y2))
+	}
 
-		// Release modifier keys
-		if holdKeys, ok := action.Params["hold_keys"]; ok {
-			if keys, ok := holdKeys.([]interface{}); ok {
-				for _, k := range keys {
-					key := normalizeKey(fmt.Sprintf("%v", k), platform)
-					robotgo.KeyToggle(key, "up")
-				}
+	// Release modifier keys
+	if holdKeys, ok := action.Params["hold_keys"]; ok {
+		if keys, ok := holdKeys.([]interface{}); ok {
+			for _, k := range keys {
+				key := normalizeKey(fmt.Sprintf("%v", k), platform)
+				robotgo.KeyToggle(key, "up")
 			}
 		}
+	}
 
-	case "type", "write":
-		text, _ := action.Params["text"].(string)
-		robotgo.TypeStr(text)
+	return nil, nil
+}
 
-	case "press":
-		key, _ := action.Params["key"].(string)
-		key = normalizeKey(key, platform)
-		robotgo.KeyTap(key)
+func handleType(action Action, platform string) (interface{}, error) {
+	text, _ := action.Params["text"].(string)
+	robotgo.TypeStr(text)
+	return nil, nil
+}
+
+func handlePress(action Action, platform string) (interface{}, error) {
+	key, _ := action.Params["key"].(string)
+	key = normalizeKey(key, platform)
+	robotgo.KeyTap(key)
+	return nil, nil
+}
 
-	case "hotkey":
-		keys, ok := action.Params["keys"].([]interface{})
-		if !ok {
-			return fmt.Errorf("hotkey requires 'keys' array")
+func handleHotkey(action Action, platform string) (interface{}, error) {
+	keys, ok := action.Params["keys"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("hotkey requires 'keys' array")
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("hotkey requires at least one key")
+	}
+
+	normalizedKeys := make([]string, len(keys))
+	for i, k := range keys {
+		normalizedKeys[i] = normalizeKey(fmt.Sprintf("%v", k), platform)
+	}
+
+	// robotgo.KeyTap takes the main key first, then modifiers
+	if len(normalizedKeys) == 1 {
+		robotgo.KeyTap(normalizedKeys[0])
+	} else {
+		// Last key is the main key, rest are modifiers
+		mainKey := normalizedKeys[len(normalizedKeys)-1]
+		modifiers := normalizedKeys[:len(normalizedKeys)-1]
+		// Convert []string to []interface{} for robotgo v1.0.0
+		modifiersInterface := make([]interface{}, len(modifiers))
+		for i, m := range modifiers {
+			modifiersInterface[i] = m
 		}
-		if len(keys) == 0 {
-			return fmt.Errorf("hotkey requires at least one key")
+		robotgo.KeyTap(mainKey, modifiersInterface...)
+		robotgo.MilliSleep(50)
+		// Ensure modifiers are released
+		for _, modifier := range modifiers {
+			robotgo.KeyToggle(modifier, "up")
 		}
+	}
+
+	return nil, nil
+}
 
-		normalizedKeys := make([]string, len(keys))
-		for i, k := range keys {
-			normalizedKeys[i] = normalizeKey(fmt.Sprintf("%v", k), platform)
+func handleKeyDown(action Action, platform string) (interface{}, error) {
+	key, _ := action.Params["key"].(string)
+	key = normalizeKey(key, platform)
+	robotgo.KeyToggle(key, "down")
+	return nil, nil
+}
+
+func handleKeyUp(action Action, platform string) (interface{}, error) {
+	key, _ := action.Params["key"].(string)
+	key = normalizeKey(key, platform)
+	robotgo.KeyToggle(key, "up")
+	return nil, nil
+}
+
+func handleScroll(action Action, platform string) (interface{}, error) {
+	x, _ := getFloat(action.Params["x"])
+	y, _ := getFloat(action.Params["y"])
+	clicks, _ := getFloat(action.Params["clicks"])
+	if x < 0 || y < 0 {
+		return nil, fmt.Errorf("invalid scroll coordinates: x=%v, y=%v", x, y)
+	}
+	x, y = resolveCoord(action.Params, x, y, platform)
+	horizontal := false
+	if h, ok := action.Params["horizontal"]; ok {
+		if hb, ok := h.(bool); ok {
+			horizontal = hb
 		}
+	}
 
-		// robotgo.KeyTap takes the main key first, then modifiers
-		if len(normalizedKeys) == 1 {
-			robotgo.KeyTap(normalizedKeys[0])
-		} else {
-			// Last key is the main key, rest are modifiers
-			mainKey := normalizedKeys[len(normalizedKeys)-1]
-			modifiers := normalizedKeys[:len(normalizedKeys)-1]
-			// Convert []string to []interface{} for robotgo v1.0.0
-			modifiersInterface := make([]interface{}, len(modifiers))
-			for i, m := range modifiers {
-				modifiersInterface[i] = m
+	// Move to position first
+	robotgo.Move(int(x), int(y))
+	robotgo.MilliSleep(500)
+
+	// robotgo.Scroll takes (x, y int) where:
+	// - y positive = scroll down, y negative = scroll up
+	// - x positive = scroll right, x negative = scroll left
+	// clicks can be positive (down/right) or negative (up/left)
+	scrollAmount := int(clicks)
+	if horizontal {
+		robotgo.Scroll(scrollAmount, 0)
+	} else {
+		robotgo.Scroll(0, scrollAmount)
+	}
+
+	return nil, nil
+}
+
+func handleWait(action Action, platform string) (interface{}, error) {
+	duration, _ := getFloat(action.Params["duration"])
+	// Convert seconds to milliseconds for MilliSleep
+	ms := int(duration * 1000)
+	robotgo.MilliSleep(ms)
+	return nil, nil
+}
+
+func handleScreenSize(action Action, platform string) (interface{}, error) {
+	w, h := robotgo.GetScreenSize()
+	n := robotgo.DisplaysNum()
+	displays := make([]map[string]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		rect := robotgo.GetScreenRect(i)
+		scale := displayScale(platform, i)
+		displays = append(displays, map[string]interface{}{
+			"index":    i,
+			"physical": map[string]int{"width": rect.W, "height": rect.H},
+			"logical":  map[string]float64{"width": float64(rect.W) / scale, "height": float64(rect.H) / scale},
+		})
+	}
+	return map[string]interface{}{"width": w, "height": h, "displays": displays}, nil
+}
+
+// handleDisplays enumerates monitors so multi-monitor and HiDPI callers can
+// translate logical/normalized coordinates into absolute physical pixels
+// themselves, or pass a "display" index and "coord_space" on positional
+// actions and let resolveCoord do it.
+func handleDisplays(action Action, platform string) (interface{}, error) {
+	n := robotgo.DisplaysNum()
+	displays := make([]map[string]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		rect := robotgo.GetScreenRect(i)
+		displays = append(displays, map[string]interface{}{
+			"index":   i,
+			"bounds":  map[string]int{"x": rect.X, "y": rect.Y, "width": rect.W, "height": rect.H},
+			"scale":   displayScale(platform, i),
+			"primary": i == 0,
+		})
+	}
+	return map[string]interface{}{"displays": displays}, nil
+}
+
+func handleRecord(action Action, platform string) (interface{}, error) {
+	path, _ := action.Params["path"].(string)
+	if path == "" {
+		return nil, fmt.Errorf("record requires 'path'")
+	}
+	stopHotkey := defaultAbortHotkey
+	if hk, ok := action.Params["abort_hotkey"].([]interface{}); ok && len(hk) > 0 {
+		stopHotkey = make([]string, len(hk))
+		for i, k := range hk {
+			stopHotkey[i] = strings.ToLower(fmt.Sprintf("%v", k))
+		}
+	}
+	n, err := recordTrack(path, stopHotkey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"path": path, "actions": n}, nil
+}
+
+func handleReplay(action Action, platform string) (interface{}, error) {
+	path, _ := action.Params["path"].(string)
+	if path == "" {
+		return nil, fmt.Errorf("replay requires 'path'")
+	}
+	repeat := 1
+	if r, err := getFloat(action.Params["repeat"]); err == nil && r > 0 {
+		repeat = int(r)
+	}
+	speed := 1.0
+	if sp, err := getFloat(action.Params["speed"]); err == nil && sp > 0 {
+		speed = sp
+	}
+	n, err := replayTrack(path, repeat, speed)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"path": path, "executed": n}, nil
+}
+
+// handleScreenshot captures the full screen, or a region if x/y/w/h are all
+// given, and optionally writes it to "path" and/or returns it as a base64
+// PNG when "return_base64" is true.
+func handleScreenshot(action Action, platform string) (interface{}, error) {
+	x, xErr := getFloat(action.Params["x"])
+	y, yErr := getFloat(action.Params["y"])
+	w, wErr := getFloat(action.Params["w"])
+	h, hErr := getFloat(action.Params["h"])
+
+	var img image.Image
+	var err error
+	if xErr == nil && yErr == nil && wErr == nil && hErr == nil && w > 0 && h > 0 {
+		x, y = resolveCoord(action.Params, x, y, platform)
+		w, h = resolveExtent(action.Params, w, h, platform)
+		img, err = robotgo.CaptureImg(int(x), int(y), int(w), int(h))
+	} else {
+		img, err = robotgo.CaptureImg()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("capturing screen: %w", err)
+	}
+
+	result := map[string]interface{}{
+		"width":  img.Bounds().Dx(),
+		"height": img.Bounds().Dy(),
+	}
+
+	if path, ok := action.Params["path"].(string); ok && path != "" {
+		if err := robotgo.Save(img, path); err != nil {
+			return nil, fmt.Errorf("saving screenshot: %w", err)
+		}
+		result["path"] = path
+	}
+
+	if returnBase64, ok := action.Params["return_base64"].(bool); ok && returnBase64 {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("encoding screenshot: %w", err)
+		}
+		result["base64"] = base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	return result, nil
+}
+
+// handlePixelColor returns the hex color of the pixel at x,y.
+func handlePixelColor(action Action, platform string) (interface{}, error) {
+	x, _ := getFloat(action.Params["x"])
+	y, _ := getFloat(action.Params["y"])
+	if x < 0 || y < 0 {
+		return nil, fmt.Errorf("invalid coordinates: x=%v, y=%v", x, y)
+	}
+	x, y = resolveCoord(action.Params, x, y, platform)
+	color := robotgo.GetPixelColor(int(x), int(y))
+	return map[string]string{"color": "#" + color}, nil
+}
+
+// handleWaitFor repeatedly samples the screen until a condition holds or
+// timeout_ms elapses, so callers can chain "wait until X, then click" without
+// polling from the parent process. Supported conditions are "pixel" (a pixel
+// reaches a target color within tolerance) and "image" (a template bitmap is
+// found on screen).
+func handleWaitFor(action Action, platform string) (interface{}, error) {
+	condition, _ := action.Params["condition"].(string)
+	timeoutMs := 5000.0
+	if t, err := getFloat(action.Params["timeout_ms"]); err == nil && t > 0 {
+		timeoutMs = t
+	}
+	deadline := time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+	start := time.Now()
+
+	switch condition {
+	case "pixel":
+		x, _ := getFloat(action.Params["x"])
+		y, _ := getFloat(action.Params["y"])
+		x, y = resolveCoord(action.Params, x, y, platform)
+		want, _ := action.Params["color"].(string)
+		tolerance, _ := getFloat(action.Params["tolerance"])
+		for {
+			got := robotgo.GetPixelColor(int(x), int(y))
+			if colorWithinTolerance(got, want, tolerance) {
+				return map[string]interface{}{
+					"matched":    true,
+					"x":          x,
+					"y":          y,
+					"color":      "#" + got,
+					"elapsed_ms": time.Since(start).Milliseconds(),
+				}, nil
+			}
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("timed out waiting for pixel (%v,%v) to become %s", x, y, want)
 			}
-			robotgo.KeyTap(mainKey, modifiersInterface...)
 			robotgo.MilliSleep(50)
-			// Ensure modifiers are released
-			for _, modifier := range modifiers {
-				robotgo.KeyToggle(modifier, "up")
+		}
+
+	case "image":
+		templatePath, _ := action.Params["template_path"].(string)
+		if templatePath == "" {
+			return nil, fmt.Errorf("waitFor condition=image requires 'template_path'")
+		}
+		threshold := 0.9
+		if th, err := getFloat(action.Params["threshold"]); err == nil && th > 0 {
+			threshold = th
+		}
+		region, hasRegion := parseRegion(action.Params["region"])
+		template := bitmapi.Open(templatePath)
+		defer robotgo.FreeBitmap(template)
+		// bitmap.Find's tolerance runs the opposite way from our
+		// match-confidence threshold: 0 demands an exact match, 1 accepts
+		// anything.
+		tolerance := 1 - threshold
+		for {
+			fx, fy := bitmapi.Find(template, nil, tolerance)
+			if fx != -1 && fy != -1 && (!hasRegion || region.contains(fx, fy)) {
+				return map[string]interface{}{
+					"matched":    true,
+					"x":          fx,
+					"y":          fy,
+					"threshold":  threshold,
+					"elapsed_ms": time.Since(start).Milliseconds(),
+				}, nil
 			}
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("timed out waiting for image %s to appear", templatePath)
+			}
+			robotgo.MilliSleep(200)
 		}
 
-	case "keyDown":
-		key, _ := action.Params["key"].(string)
-		key = normalizeKey(key, platform)
-		robotgo.KeyToggle(key, "down")
+	default:
+		return nil, fmt.Errorf("unknown waitFor condition: %s", condition)
+	}
+}
 
-	case "keyUp":
-		key, _ := action.Params["key"].(string)
-		key = normalizeKey(key, platform)
-		robotgo.KeyToggle(key, "up")
+// imageRegion is an inclusive x,y,w,h rectangle used to constrain waitFor's
+// "image" condition to a sub-area of the screen.
+type imageRegion struct {
+	x, y, w, h int
+}
 
-	case "scroll":
-		x, _ := getFloat(action.Params["x"])
-		y, _ := getFloat(action.Params["y"])
-		clicks, _ := getFloat(action.Params["clicks"])
-		if x < 0 || y < 0 {
-			return fmt.Errorf("invalid scroll coordinates: x=%v, y=%v", x, y)
-		}
-		horizontal := false
-		if h, ok := action.Params["horizontal"]; ok {
-			if hb, ok := h.(bool); ok {
-				horizontal = hb
+func (r imageRegion) contains(x, y int) bool {
+	return x >= r.x && x < r.x+r.w && y >= r.y && y < r.y+r.h
+}
+
+// parseRegion reads a "region" param shaped as [x,y,w,h].
+func parseRegion(v interface{}) (imageRegion, bool) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) != 4 {
+		return imageRegion{}, false
+	}
+	vals := make([]int, 4)
+	for i, a := range arr {
+		f, err := getFloat(a)
+		if err != nil {
+			return imageRegion{}, false
+		}
+		vals[i] = int(f)
+	}
+	return imageRegion{x: vals[0], y: vals[1], w: vals[2], h: vals[3]}, true
+}
+
+// colorWithinTolerance compares two hex colors (with or without a leading
+// '#') channel-by-channel, allowing up to tolerance difference per channel.
+func colorWithinTolerance(gotHex, wantHex string, tolerance float64) bool {
+	gr, gg, gb, err1 := parseHexColor(gotHex)
+	wr, wg, wb, err2 := parseHexColor(wantHex)
+	if err1 != nil || err2 != nil {
+		return strings.EqualFold(gotHex, wantHex)
+	}
+	return math.Abs(float64(gr-wr)) <= tolerance &&
+		math.Abs(float64(gg-wg)) <= tolerance &&
+		math.Abs(float64(gb-wb)) <= tolerance
+}
+
+func parseHexColor(hex string) (r, g, b int, err error) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return 0, 0, 0, fmt.Errorf("invalid hex color: %s", hex)
+	}
+	rv, err := strconv.ParseInt(hex[0:2], 16, 32)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	gv, err := strconv.ParseInt(hex[2:4], 16, 32)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	bv, err := strconv.ParseInt(hex[4:6], 16, 32)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return int(rv), int(gv), int(bv), nil
+}
+
+// recordTrack captures global mouse/keyboard events into a JSON array of
+// Actions, stopping when stopHotkey is pressed. Sequential typed characters
+// are coalesced into a single "type" action, and mouse moves are collapsed
+// into a single terminal "moveTo" emitted right before the click or scroll
+// that follows them. Gaps between events longer than 50ms are preserved as
+// explicit "wait" actions so replay reproduces the original timing.
+//
+// Events are read from inputFeed.subscribeRaw() rather than a private
+// hook.Start()/hook.Register() pair, so a concurrent -server connection's
+// input feed (or another record/replay action) shares the one process-global
+// gohook listener instead of racing to start and end it.
+func recordTrack(path string, stopHotkey []string) (int, error) {
+	var actions []Action
+	var lastEventTime time.Time
+	var pendingText strings.Builder
+	var pendingX, pendingY int16
+	havePendingMove := false
+
+	flushText := func() {
+		if pendingText.Len() > 0 {
+			actions = append(actions, Action{Type: "type", Params: map[string]interface{}{"text": pendingText.String()}})
+			pendingText.Reset()
+		}
+	}
+	flushMove := func() {
+		if havePendingMove {
+			actions = append(actions, Action{Type: "moveTo", Params: map[string]interface{}{"x": float64(pendingX), "y": float64(pendingY)}})
+			havePendingMove = false
+		}
+	}
+	recordGap := func(now time.Time) {
+		if !lastEventTime.IsZero() {
+			if gap := now.Sub(lastEventTime); gap > 50*time.Millisecond {
+				actions = append(actions, Action{Type: "wait", Params: map[string]interface{}{"duration": gap.Seconds()}})
 			}
 		}
+		lastEventTime = now
+	}
 
-		// Move to position first
-		robotgo.Move(int(x), int(y))
-		robotgo.MilliSleep(500)
-
-		// robotgo.Scroll takes (x, y int) where:
-		// - y positive = scroll down, y negative = scroll up
-		// - x positive = scroll right, x negative = scroll left
-		// clicks can be positive (down/right) or negative (up/left)
-		scrollAmount := int(clicks)
-		if horizontal {
-			robotgo.Scroll(scrollAmount, 0)
-		} else {
-			robotgo.Scroll(0, scrollAmount)
+	// heldModifiers tracks which modifier keys are currently down, in press
+	// order, so a non-modifier KeyDown while any are held records as a
+	// single "hotkey" action (e.g. ctrl+c) instead of two independent
+	// "press" actions that wouldn't reproduce the shortcut on replay.
+	var heldModifiers []string
+
+	raw := inputFeed.subscribeRaw()
+	defer inputFeed.unsubscribeRaw(raw)
+
+recording:
+	for e := range raw {
+		switch e.Kind {
+		case hook.MouseMove:
+			flushText()
+			pendingX, pendingY = e.X, e.Y
+			havePendingMove = true
+		case hook.MouseDrag:
+			pendingX, pendingY = e.X, e.Y
+			havePendingMove = true
+		case hook.MouseDown:
+			now := time.Now()
+			recordGap(now)
+			flushText()
+			flushMove()
+			actions = append(actions, Action{Type: "click", Params: map[string]interface{}{
+				"x": float64(e.X), "y": float64(e.Y), "button": mouseButtonName(uint8(e.Button)),
+			}})
+		case hook.MouseWheel:
+			now := time.Now()
+			recordGap(now)
+			flushText()
+			flushMove()
+			actions = append(actions, Action{Type: "scroll", Params: map[string]interface{}{
+				"x": float64(e.X), "y": float64(e.Y), "clicks": float64(e.Rotation),
+			}})
+		case hook.KeyUp:
+			name := strings.ToLower(hook.RawcodetoKeychar(e.Rawcode))
+			if !isModifierKey(name) {
+				continue
+			}
+			heldModifiers = removeHeldModifier(heldModifiers, name)
+		case hook.KeyDown:
+			now := time.Now()
+			name := strings.ToLower(hook.RawcodetoKeychar(e.Rawcode))
+
+			if isModifierKey(name) {
+				recordGap(now)
+				heldModifiers = addHeldModifier(heldModifiers, name)
+				continue
+			}
+
+			combo := append(append([]string{}, heldModifiers...), name)
+			if sameKeySet(combo, stopHotkey) {
+				break recording
+			}
+
+			recordGap(now)
+			if len(heldModifiers) > 0 {
+				flushText()
+				flushMove()
+				keys := make([]interface{}, 0, len(heldModifiers)+1)
+				for _, m := range heldModifiers {
+					keys = append(keys, m)
+				}
+				keys = append(keys, name)
+				actions = append(actions, Action{Type: "hotkey", Params: map[string]interface{}{"keys": keys}})
+				continue
+			}
+
+			if e.Keychar != 0 && e.Keychar != 65535 {
+				flushMove()
+				pendingText.WriteRune(rune(e.Keychar))
+			} else {
+				flushText()
+				flushMove()
+				actions = append(actions, Action{Type: "press", Params: map[string]interface{}{"key": name}})
+			}
 		}
+	}
 
-	case "wait":
-		duration, _ := getFloat(action.Params["duration"])
-		// Convert seconds to milliseconds for MilliSleep
-		ms := int(duration * 1000)
-		robotgo.MilliSleep(ms)
+	flushText()
+	flushMove()
 
-	case "screenSize":
-		// Return screen size as JSON
-		w, h := robotgo.GetScreenSize()
-		fmt.Printf(`{"width":%d,"height":%d}`, w, h)
-		return nil
+	data, err := json.MarshalIndent(actions, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, err
+	}
+	return len(actions), nil
+}
 
+// modifierKeyNames are the gohook key names (as returned by
+// hook.RawcodetoKeychar) recordTrack tracks as held modifiers for "hotkey"
+// detection.
+var modifierKeyNames = map[string]bool{
+	"ctrl":    true,
+	"control": true,
+	"shift":   true,
+	"alt":     true,
+	"option":  true,
+	"cmd":     true,
+	"command": true,
+	"win":     true,
+	"super":   true,
+	"meta":    true,
+}
+
+func isModifierKey(name string) bool {
+	return modifierKeyNames[name]
+}
+
+// addHeldModifier appends name to held if it isn't already present.
+func addHeldModifier(held []string, name string) []string {
+	for _, m := range held {
+		if m == name {
+			return held
+		}
+	}
+	return append(held, name)
+}
+
+// removeHeldModifier removes name from held, if present.
+func removeHeldModifier(held []string, name string) []string {
+	for i, m := range held {
+		if m == name {
+			return append(held[:i], held[i+1:]...)
+		}
+	}
+	return held
+}
+
+// sameKeySet reports whether a and b name the same keys, ignoring order and
+// case. Used to recognize when a recorded combo is the abort hotkey itself,
+// so it isn't captured as a trailing action right before the recording ends.
+func sameKeySet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, k := range a {
+		set[strings.ToLower(k)] = true
+	}
+	for _, k := range b {
+		if !set[strings.ToLower(k)] {
+			return false
+		}
+	}
+	return true
+}
+
+// mouseButtonName converts a gohook button code to the button name used by
+// the "click" action's "button" param.
+func mouseButtonName(button uint8) string {
+	switch button {
+	case 2:
+		return "right"
+	case 3:
+		return "center"
 	default:
-		return fmt.Errorf("unknown action type: %s", action.Type)
+		return "left"
+	}
+}
+
+// replayTrack reads a track file written by recordTrack and re-executes its
+// actions repeat times, scaling any recorded "wait" gaps by speed. Pressing
+// the default abort hotkey (ctrl+shift+q) stops the replay early.
+//
+// The abort hotkey is watched via inputFeed.subscribeRaw() rather than a
+// private hook.Start()/hook.Register()/hook.End() sequence, so a concurrent
+// -server connection's input feed (or another record/replay action) shares
+// the one process-global gohook listener instead of one side's hook.End()
+// silently killing the other's feed.
+func replayTrack(path string, repeat int, speed float64) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var actions []Action
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return 0, err
+	}
+
+	aborted := make(chan struct{})
+	done := make(chan struct{})
+	var stopped int32
+	raw := inputFeed.subscribeRaw()
+	go func() {
+		defer inputFeed.unsubscribeRaw(raw)
+		var heldModifiers []string
+		for {
+			select {
+			case e, ok := <-raw:
+				if !ok {
+					return
+				}
+				name := strings.ToLower(hook.RawcodetoKeychar(e.Rawcode))
+				switch e.Kind {
+				case hook.KeyUp:
+					if !isModifierKey(name) {
+						continue
+					}
+					heldModifiers = removeHeldModifier(heldModifiers, name)
+				case hook.KeyDown:
+					if isModifierKey(name) {
+						heldModifiers = addHeldModifier(heldModifiers, name)
+						continue
+					}
+					combo := append(append([]string{}, heldModifiers...), name)
+					if sameKeySet(combo, defaultAbortHotkey) {
+						if atomic.CompareAndSwapInt32(&stopped, 0, 1) {
+							close(aborted)
+						}
+					}
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	defer close(done)
+
+	executed := 0
+	for r := 0; r < repeat; r++ {
+		for _, act := range actions {
+			select {
+			case <-aborted:
+				return executed, nil
+			default:
+			}
+			if act.Type == "wait" {
+				duration, _ := getFloat(act.Params["duration"])
+				act.Params = map[string]interface{}{"duration": duration / speed}
+			}
+			if _, err := executeAction(act); err != nil {
+				return executed, err
+			}
+			executed++
+		}
+	}
+	return executed, nil
+}
+
+// executeAction dispatches a GUI action to its registered handler and
+// returns a JSON-serializable result on success.
+func executeAction(action Action) (interface{}, error) {
+	platform := action.Platform
+	if platform == "" {
+		platform = runtime.GOOS
+	}
+
+	handler, ok := actionRegistry[action.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown action type: %s", action.Type)
+	}
+	return handler(action, platform)
+}
+
+// MotionSpec describes how to interpolate a cursor move across multiple
+// steps instead of teleporting directly to the target, via the optional
+// "motion" param on "click", "moveTo", and "dragTo" actions.
+type MotionSpec struct {
+	Type       string // "bezier", "linear", or "easeInOut"
+	DurationMs int
+	Jitter     float64
+	Steps      int
+	Seed       int64
+	HasSeed    bool
+}
+
+// parseMotion reads the optional "motion" param. It returns nil when no
+// motion object is present, in which case callers fall back to the
+// historical teleport-then-act behavior.
+func parseMotion(params map[string]interface{}) *MotionSpec {
+	raw, ok := params["motion"]
+	if !ok {
+		return nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	spec := &MotionSpec{Type: "linear", DurationMs: 300, Steps: 30}
+	if t, ok := m["type"].(string); ok && t != "" {
+		spec.Type = t
+	}
+	if d, err := getFloat(m["duration_ms"]); err == nil && d > 0 {
+		spec.DurationMs = int(d)
+	}
+	if j, err := getFloat(m["jitter"]); err == nil {
+		spec.Jitter = j
+	}
+	if s, err := getFloat(m["steps"]); err == nil && s > 0 {
+		spec.Steps = int(s)
+	}
+	if sd, err := getFloat(m["seed"]); err == nil {
+		spec.Seed = int64(sd)
+		spec.HasSeed = true
+	}
+	return spec
+}
+
+// moveSmooth moves the cursor from (fromX, fromY) to (toX, toY). With a nil
+// motion it teleports like robotgo.Move always has. With a motion it samples
+// "linear", "easeInOut", or a random cubic "bezier" path across motion.Steps,
+// sleeping motion.DurationMs/Steps between samples and adding gaussian
+// jitter per step when motion.Jitter is set.
+func moveSmooth(fromX, fromY, toX, toY float64, motion *MotionSpec) {
+	if motion == nil {
+		robotgo.Move(int(toX), int(toY))
+		return
+	}
+
+	steps := motion.Steps
+	if steps <= 0 {
+		steps = 30
+	}
+	sleepPer := time.Duration(motion.DurationMs) * time.Millisecond / time.Duration(steps)
+
+	var rng *rand.Rand
+	if motion.HasSeed {
+		rng = rand.New(rand.NewSource(motion.Seed))
+	} else {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	var c1x, c1y, c2x, c2y float64
+	if motion.Type == "bezier" {
+		minX, maxX := math.Min(fromX, toX), math.Max(fromX, toX)
+		minY, maxY := math.Min(fromY, toY), math.Max(fromY, toY)
+		c1x, c1y = minX+rng.Float64()*(maxX-minX), minY+rng.Float64()*(maxY-minY)
+		c2x, c2y = minX+rng.Float64()*(maxX-minX), minY+rng.Float64()*(maxY-minY)
+	}
+
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		var x, y float64
+		switch motion.Type {
+		case "bezier":
+			x, y = cubicBezier(fromX, fromY, c1x, c1y, c2x, c2y, toX, toY, t)
+		case "easeInOut":
+			et := easeInOut(t)
+			x, y = fromX+(toX-fromX)*et, fromY+(toY-fromY)*et
+		default: // "linear"
+			x, y = fromX+(toX-fromX)*t, fromY+(toY-fromY)*t
+		}
+		if motion.Jitter > 0 && i < steps {
+			x += rng.NormFloat64() * motion.Jitter
+			y += rng.NormFloat64() * motion.Jitter
+		}
+		robotgo.Move(int(x), int(y))
+		if i < steps {
+			time.Sleep(sleepPer)
+		}
 	}
+	// Land exactly on target regardless of accumulated jitter.
+	robotgo.Move(int(toX), int(toY))
+}
 
-	return nil
+// easeInOut is a standard quadratic ease-in-out curve over t in [0,1].
+func easeInOut(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return -1 + (4-2*t)*t
+}
+
+// cubicBezier samples a cubic Bezier curve defined by endpoints (x0,y0),
+// (x3,y3) and control points (x1,y1), (x2,y2) at parameter t in [0,1].
+func cubicBezier(x0, y0, x1, y1, x2, y2, x3, y3, t float64) (float64, float64) {
+	u := 1 - t
+	x := u*u*u*x0 + 3*u*u*t*x1 + 3*u*t*t*x2 + t*t*t*x3
+	y := u*u*u*y0 + 3*u*u*t*y1 + 3*u*t*t*y2 + t*t*t*y3
+	return x, y
 }
 
 func getFloat(v interface{}) (float64, error) {
@@ -295,13 +1134,176 @@ func getFloat(v interface{}) (float64, error) {
 	}
 }
 
+// resultFor converts an executeAction outcome into the wire-level ActionResult.
+func resultFor(result interface{}, err error) ActionResult {
+	if err != nil {
+		return ActionResult{OK: false, Error: err.Error()}
+	}
+	return ActionResult{OK: true, Result: result}
+}
+
+// writeResult writes a single JSON response line to w.
+func writeResult(w *bufio.Writer, result ActionResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		// Marshaling ActionResult itself should never fail, but don't take
+		// the daemon down if it somehow does.
+		fmt.Fprintf(w, `{"ok":false,"error":%q}`+"\n", err.Error())
+		w.Flush()
+		return
+	}
+	w.Write(data)
+	w.WriteString("\n")
+	w.Flush()
+}
+
+// runBatch executes actions sequentially, writing one ActionResult line per
+// action. If onError is "abort" (the default) the batch stops at the first
+// failing action; "continue" runs every action regardless.
+func runBatch(w *bufio.Writer, batch []Action, delay time.Duration, onError string) {
+	if len(batch) == 0 {
+		// The daemon protocol is one response per input line; an empty
+		// batch still needs an ack or the caller hangs waiting for one.
+		writeResult(w, ActionResult{OK: true, Result: map[string]int{"executed": 0}})
+		return
+	}
+	for _, action := range batch {
+		result, err := executeAction(action)
+		writeResult(w, resultFor(result, err))
+		if err != nil && onError != "continue" {
+			return
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+	}
+}
+
+// runDaemon keeps the process alive, reading newline-delimited JSON actions
+// (or {"batch":[...]} requests) from stdin and writing one JSON
+// ActionResult per action to stdout. This avoids paying Go/robotgo process
+// startup cost per action when a caller issues many actions in a row.
+func runDaemon() {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	writer := bufio.NewWriter(os.Stdout)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var batchReq BatchRequest
+		if err := json.Unmarshal([]byte(line), &batchReq); err == nil && batchReq.Batch != nil {
+			onError := batchReq.OnError
+			if onError == "" {
+				onError = "abort"
+			}
+			runBatch(writer, batchReq.Batch, time.Duration(batchReq.DelayMs)*time.Millisecond, onError)
+			continue
+		}
+
+		var action Action
+		if err := json.Unmarshal([]byte(line), &action); err != nil {
+			writeResult(writer, ActionResult{OK: false, Error: fmt.Sprintf("parsing JSON: %v", err)})
+			continue
+		}
+		result, err := executeAction(action)
+		writeResult(writer, resultFor(result, err))
+	}
+}
+
+// runRecordCLI handles `main record -path track.json`, the CLI counterpart
+// to the "record" action.
+func runRecordCLI(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	path := fs.String("path", "", "Track file to write recorded actions to")
+	fs.Parse(args)
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "Error: record requires -path")
+		os.Exit(1)
+	}
+	n, err := recordTrack(*path, defaultAbortHotkey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error recording: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(`{"path":%q,"actions":%d}`+"\n", *path, n)
+}
+
+// runReplayCLI handles `main replay -path track.json`, the CLI counterpart
+// to the "replay" action.
+func runReplayCLI(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	path := fs.String("path", "", "Track file to replay")
+	repeat := fs.Int("repeat", 1, "Number of times to replay the track")
+	speed := fs.Float64("speed", 1.0, "Speed multiplier (2.0 = twice as fast)")
+	fs.Parse(args)
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "Error: replay requires -path")
+		os.Exit(1)
+	}
+	n, err := replayTrack(*path, *repeat, *speed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error replaying: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(`{"path":%q,"executed":%d}`+"\n", *path, n)
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "record":
+			runRecordCLI(os.Args[2:])
+			return
+		case "replay":
+			runReplayCLI(os.Args[2:])
+			return
+		}
+	}
+
 	var jsonInput string
 	var platform string
+	var daemon bool
+	var serverAddr string
+	var token string
+	var rateLimit int
+	var allowNoAuth bool
+	var allowedOrigins string
 	flag.StringVar(&jsonInput, "json", "", "JSON action to execute")
 	flag.StringVar(&platform, "platform", "", "Platform (darwin, windows, linux)")
+	flag.BoolVar(&daemon, "daemon", false, "Run in persistent daemon mode, reading newline-delimited JSON actions from stdin")
+	flag.BoolVar(&daemon, "serve", false, "Alias for -daemon")
+	flag.StringVar(&serverAddr, "server", "", "Run in remote server mode, listening on this address (e.g. :8080) for WebSocket/WebRTC controllers")
+	flag.StringVar(&token, "token", "", "Auth token remote controllers must present (defaults to $ROBOTGO_EXECUTOR_TOKEN)")
+	flag.IntVar(&rateLimit, "rate-limit", 50, "Max actions per second accepted from a single remote controller")
+	flag.BoolVar(&allowNoAuth, "allow-no-auth", false, "Required to start -server mode without a token; this gives anyone who can reach the address full input/screenshot control of the host")
+	flag.StringVar(&allowedOrigins, "allowed-origin", "", "Comma-separated browser Origin values allowed to open the WebSocket endpoint (required for browser controllers; non-browser clients send no Origin and are unaffected)")
 	flag.Parse()
 
+	if serverAddr != "" {
+		if token == "" {
+			token = os.Getenv("ROBOTGO_EXECUTOR_TOKEN")
+		}
+		if token == "" && !allowNoAuth {
+			fmt.Fprintln(os.Stderr, "Error: -server requires -token (or $ROBOTGO_EXECUTOR_TOKEN); pass -allow-no-auth to explicitly run without authentication")
+			os.Exit(1)
+		}
+		var origins []string
+		if allowedOrigins != "" {
+			origins = strings.Split(allowedOrigins, ",")
+		}
+		runServer(serverConfig{Addr: serverAddr, Token: token, RateLimitPerS: rateLimit, AllowedOrigins: origins})
+		return
+	}
+
+	if daemon {
+		runDaemon()
+		return
+	}
+
 	if jsonInput == "" {
 		// Try reading from stdin
 		var input []byte
@@ -324,8 +1326,13 @@ func main() {
 		action.Platform = platform
 	}
 
-	if err := executeAction(action); err != nil {
+	result, err := executeAction(action)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error executing action: %v\n", err)
 		os.Exit(1)
 	}
+	if result != nil {
+		data, _ := json.Marshal(result)
+		fmt.Println(string(data))
+	}
 }
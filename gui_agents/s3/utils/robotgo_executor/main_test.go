@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCubicBezierEndpoints(t *testing.T) {
+	x0, y0 := 10.0, 20.0
+	x3, y3 := 110.0, 220.0
+	c1x, c1y := 40.0, 30.0
+	c2x, c2y := 80.0, 200.0
+
+	x, y := cubicBezier(x0, y0, c1x, c1y, c2x, c2y, x3, y3, 0)
+	if x != x0 || y != y0 {
+		t.Errorf("t=0: got (%v,%v), want start (%v,%v)", x, y, x0, y0)
+	}
+
+	x, y = cubicBezier(x0, y0, c1x, c1y, c2x, c2y, x3, y3, 1)
+	if x != x3 || y != y3 {
+		t.Errorf("t=1: got (%v,%v), want end (%v,%v)", x, y, x3, y3)
+	}
+}
+
+func TestCubicBezierMidpointStaysInBoundingBox(t *testing.T) {
+	x, y := cubicBezier(0, 0, 25, 75, 75, 25, 100, 100, 0.5)
+	if x < 0 || x > 100 || y < 0 || y > 100 {
+		t.Errorf("midpoint (%v,%v) escaped the [0,100] control bounding box", x, y)
+	}
+}
+
+func TestEaseInOutEndpointsAndMidpoint(t *testing.T) {
+	if got := easeInOut(0); got != 0 {
+		t.Errorf("easeInOut(0) = %v, want 0", got)
+	}
+	if got := easeInOut(1); got != 1 {
+		t.Errorf("easeInOut(1) = %v, want 1", got)
+	}
+	if got := easeInOut(0.5); got != 0.5 {
+		t.Errorf("easeInOut(0.5) = %v, want 0.5", got)
+	}
+}
+
+func TestEaseInOutMonotonic(t *testing.T) {
+	prev := -1.0
+	for i := 0; i <= 10; i++ {
+		got := easeInOut(float64(i) / 10)
+		if got < prev {
+			t.Fatalf("easeInOut must be non-decreasing over [0,1], dropped at step %d", i)
+		}
+		prev = got
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	r, g, b, err := parseHexColor("#ff00aa")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r != 255 || g != 0 || b != 170 {
+		t.Errorf("got (%d,%d,%d), want (255,0,170)", r, g, b)
+	}
+
+	r, g, b, err = parseHexColor("336699")
+	if err != nil {
+		t.Fatalf("unexpected error for no-leading-# hex: %v", err)
+	}
+	if r != 0x33 || g != 0x66 || b != 0x99 {
+		t.Errorf("got (%d,%d,%d), want (51,102,153)", r, g, b)
+	}
+
+	if _, _, _, err := parseHexColor("xyz"); err == nil {
+		t.Error("expected an error for an invalid hex color")
+	}
+}
+
+func TestColorWithinTolerance(t *testing.T) {
+	cases := []struct {
+		got, want string
+		tolerance float64
+		within    bool
+	}{
+		{"ff00aa", "ff00aa", 0, true},
+		{"ff00aa", "fe01ab", 2, true},
+		{"ff00aa", "0000aa", 2, false},
+		{"not-a-color", "not-a-color", 0, true}, // non-hex falls back to string equality
+		{"not-a-color", "other", 0, false},
+	}
+	for _, c := range cases {
+		if got := colorWithinTolerance(c.got, c.want, c.tolerance); got != c.within {
+			t.Errorf("colorWithinTolerance(%q, %q, %v) = %v, want %v", c.got, c.want, c.tolerance, got, c.within)
+		}
+	}
+}
+
+func TestResolveCoordPassesThroughWithoutDisplayOrCoordSpace(t *testing.T) {
+	x, y := resolveCoord(map[string]interface{}{}, 12.5, 34.5, "linux")
+	if x != 12.5 || y != 34.5 {
+		t.Errorf("got (%v,%v), want coordinates unchanged (12.5,34.5)", x, y)
+	}
+}
+
+func TestResolveCoordLogicalWithoutDisplayIsUnscaledOnNonDarwin(t *testing.T) {
+	// On non-darwin platforms displayScale always returns 1, so a "logical"
+	// coord_space with no "display" index set (no native GetScreenRect call
+	// needed) should pass x,y through unchanged.
+	x, y := resolveCoord(map[string]interface{}{"coord_space": "logical"}, 7, 9, "linux")
+	if x != 7 || y != 9 {
+		t.Errorf("got (%v,%v), want (7,9)", x, y)
+	}
+}
+
+func TestResolveExtentPassesThroughWithoutCoordSpace(t *testing.T) {
+	w, h := resolveExtent(map[string]interface{}{}, 100, 50, "linux")
+	if w != 100 || h != 50 {
+		t.Errorf("got (%v,%v), want extent unchanged (100,50)", w, h)
+	}
+}
+
+func TestResolveExtentLogicalIsUnscaledOnNonDarwin(t *testing.T) {
+	// On non-darwin platforms displayScale always returns 1, so a "logical"
+	// coord_space with no "display" index set (no native GetScreenRect call
+	// needed) should pass w,h through unchanged.
+	w, h := resolveExtent(map[string]interface{}{"coord_space": "logical"}, 100, 50, "linux")
+	if w != 100 || h != 50 {
+		t.Errorf("got (%v,%v), want (100,50)", w, h)
+	}
+}
+
+func TestSameKeySet(t *testing.T) {
+	if !sameKeySet([]string{"ctrl", "SHIFT", "q"}, []string{"q", "ctrl", "shift"}) {
+		t.Error("expected sameKeySet to ignore order and case")
+	}
+	if sameKeySet([]string{"ctrl", "q"}, []string{"ctrl", "shift", "q"}) {
+		t.Error("expected sameKeySet to reject sets of different sizes")
+	}
+	if sameKeySet([]string{"ctrl", "alt"}, []string{"ctrl", "shift"}) {
+		t.Error("expected sameKeySet to reject non-matching sets of the same size")
+	}
+}
+
+func TestIsModifierKey(t *testing.T) {
+	for _, k := range []string{"ctrl", "shift", "alt", "cmd", "super"} {
+		if !isModifierKey(k) {
+			t.Errorf("expected %q to be a modifier key", k)
+		}
+	}
+	if isModifierKey("q") {
+		t.Error("expected 'q' not to be a modifier key")
+	}
+}
+
+func TestAddHeldModifier(t *testing.T) {
+	held := addHeldModifier(nil, "ctrl")
+	held = addHeldModifier(held, "ctrl")
+	if len(held) != 1 {
+		t.Errorf("got %v, want adding an already-held modifier to be a no-op", held)
+	}
+	held = addHeldModifier(held, "shift")
+	if len(held) != 2 || held[0] != "ctrl" || held[1] != "shift" {
+		t.Errorf("got %v, want [ctrl shift] in press order", held)
+	}
+}
+
+func TestRemoveHeldModifier(t *testing.T) {
+	held := []string{"ctrl", "shift"}
+	held = removeHeldModifier(held, "ctrl")
+	if len(held) != 1 || held[0] != "shift" {
+		t.Errorf("got %v, want [shift]", held)
+	}
+	held = removeHeldModifier(held, "alt")
+	if len(held) != 1 || held[0] != "shift" {
+		t.Errorf("got %v, want removing an unheld modifier to be a no-op", held)
+	}
+}
+
+func TestParseMotionDefaults(t *testing.T) {
+	m := parseMotion(map[string]interface{}{"motion": map[string]interface{}{}})
+	if m == nil {
+		t.Fatal("expected a non-nil MotionSpec")
+	}
+	if m.Type != "linear" || m.DurationMs != 300 || m.Steps != 30 {
+		t.Errorf("got %+v, want defaults {linear 300 .. 30 .. }", m)
+	}
+}
+
+func TestParseMotionMissingIsNil(t *testing.T) {
+	if m := parseMotion(map[string]interface{}{}); m != nil {
+		t.Errorf("expected nil MotionSpec without a \"motion\" param, got %+v", m)
+	}
+}
+
+func TestResultForSuccess(t *testing.T) {
+	r := resultFor(map[string]interface{}{"x": 1.0}, nil)
+	if !r.OK || r.Error != "" {
+		t.Errorf("got %+v, want OK with no error", r)
+	}
+}
+
+func TestResultForError(t *testing.T) {
+	r := resultFor(nil, errors.New("boom"))
+	if r.OK || r.Error != "boom" {
+		t.Errorf("got %+v, want a failed result with error \"boom\"", r)
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeResult(w, ActionResult{OK: true, Result: map[string]int{"n": 2}})
+
+	var got ActionResult
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output wasn't valid JSON: %v (got %q)", err, buf.String())
+	}
+	if !got.OK {
+		t.Errorf("got %+v, want ok:true", got)
+	}
+	if buf.Bytes()[buf.Len()-1] != '\n' {
+		t.Error("expected writeResult to terminate its line with a newline")
+	}
+}
+
+// unknownAction is guaranteed to fail in executeAction without touching any
+// real input device, since actionRegistry has no handler for it.
+const unknownAction = `{"type":"does-not-exist"}`
+
+func TestRunBatchEmptyBatchStillAcks(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	runBatch(w, nil, 0, "abort")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want exactly one ack for an empty batch", len(lines))
+	}
+	var got ActionResult
+	if err := json.Unmarshal(lines[0], &got); err != nil {
+		t.Fatalf("ack wasn't valid JSON: %v", err)
+	}
+	if !got.OK {
+		t.Errorf("got %+v, want ok:true for an empty batch", got)
+	}
+}
+
+func TestRunBatchAbortsOnErrorByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	batch := []Action{{Type: "does-not-exist"}, {Type: "also-does-not-exist"}}
+	runBatch(w, batch, 0, "abort")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 1 {
+		t.Fatalf("got %d result lines, want exactly one (batch should abort after the first failure)", len(lines))
+	}
+}
+
+func TestRunBatchContinuesOnErrorWhenRequested(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	batch := []Action{{Type: "does-not-exist"}, {Type: "also-does-not-exist"}}
+	runBatch(w, batch, 0, "continue")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d result lines, want one per action when on_error is \"continue\"", len(lines))
+	}
+	for _, line := range lines {
+		var got ActionResult
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Fatalf("result line wasn't valid JSON: %v", err)
+		}
+		if got.OK {
+			t.Errorf("got %+v, want every unknown-type action to fail", got)
+		}
+	}
+}
+
+// withDaemonIO temporarily redirects os.Stdin/os.Stdout to pipes so
+// runDaemon's stdin-scan/stdout-write loop can be driven from a test, then
+// restores the originals.
+func withDaemonIO(t *testing.T, input string) string {
+	t.Helper()
+
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("creating stdin pipe: %v", err)
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("creating stdout pipe: %v", err)
+	}
+
+	origStdin, origStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = inR, outW
+	defer func() { os.Stdin, os.Stdout = origStdin, origStdout }()
+
+	done := make(chan struct{})
+	var output []byte
+	go func() {
+		output, _ = io.ReadAll(outR)
+		close(done)
+	}()
+
+	inW.WriteString(input)
+	inW.Close()
+
+	runDaemon()
+	outW.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out reading runDaemon's output")
+	}
+	return string(output)
+}
+
+func TestRunDaemonDispatchesBareAction(t *testing.T) {
+	out := withDaemonIO(t, unknownAction+"\n")
+
+	var got ActionResult
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output wasn't valid JSON: %v (got %q)", err, out)
+	}
+	if got.OK {
+		t.Error("expected an unknown action type to fail")
+	}
+}
+
+func TestRunDaemonDispatchesBatchRequest(t *testing.T) {
+	out := withDaemonIO(t, `{"batch":[]}`+"\n")
+
+	var got ActionResult
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output wasn't valid JSON: %v (got %q)", err, out)
+	}
+	if !got.OK {
+		t.Errorf("got %+v, want an empty {\"batch\":[]} request to ack with ok:true", got)
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v3"
+	hook "github.com/robotn/gohook"
+)
+
+// serverConfig holds the settings for -server mode: a remote input channel
+// so a browser-based controller can stream clicks, keystrokes, and scrolls
+// with sub-frame latency instead of shelling out per action.
+type serverConfig struct {
+	Addr           string
+	Token          string
+	RateLimitPerS  int
+	AllowedOrigins []string
+}
+
+// wsMessage is the wire format exchanged with remote controllers over both
+// the WebSocket and WebRTC data channel transports: either an Action to
+// execute, or (outbound) an input-feed event or action result.
+type wsMessage struct {
+	Type   string                 `json:"type"` // "action" | "ack" | "event" | "error"
+	Action *Action                `json:"action,omitempty"`
+	Event  map[string]interface{} `json:"event,omitempty"`
+	Result interface{}            `json:"result,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// rateLimiter is a simple token-bucket limiter shared by the actions one
+// connection submits, refilling RateLimitPerS tokens every second.
+type rateLimiter struct {
+	mu       sync.Mutex
+	tokens   float64
+	max      float64
+	refillPS float64
+	last     time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	if perSecond <= 0 {
+		perSecond = 50
+	}
+	return &rateLimiter{tokens: float64(perSecond), max: float64(perSecond), refillPS: float64(perSecond), last: time.Now()}
+}
+
+func (r *rateLimiter) Allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.refillPS
+	if r.tokens > r.max {
+		r.tokens = r.max
+	}
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// runServer starts -server mode: a WebSocket endpoint at /ws and a WebRTC
+// signaling endpoint at /rtc, both authenticated with the same token and
+// dispatching through the same actionRegistry used by the CLI and daemon
+// paths.
+func runServer(cfg serverConfig) {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin:     allowedOriginChecker(cfg.AllowedOrigins),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		if !checkToken(w, r, cfg.Token) {
+			return
+		}
+		serveWS(w, r, cfg, upgrader)
+	})
+	mux.HandleFunc("/rtc", func(w http.ResponseWriter, r *http.Request) {
+		if !checkToken(w, r, cfg.Token) {
+			return
+		}
+		serveRTCOffer(w, r, cfg)
+	})
+
+	log.Printf("robotgo_executor: listening on %s (ws: /ws, webrtc signaling: /rtc)", cfg.Addr)
+	if err := http.ListenAndServe(cfg.Addr, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "server error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// allowedOriginChecker builds a websocket.Upgrader.CheckOrigin that only
+// accepts the operator's explicit -allowed-origin allow-list. Requests with
+// no Origin header (CLI/server-to-server clients; browsers always send one)
+// are allowed through, since CheckOrigin exists specifically to stop a
+// malicious web page's browser-issued cross-origin WebSocket from reaching
+// this server.
+func allowedOriginChecker(allowed []string) func(r *http.Request) bool {
+	allowSet := make(map[string]bool, len(allowed))
+	for _, o := range allowed {
+		if o = strings.TrimSpace(o); o != "" {
+			allowSet[o] = true
+		}
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		return allowSet[origin]
+	}
+}
+
+// checkToken requires the configured token on every request, comparing in
+// constant time since this is the sole auth gate on remote machine control.
+// An empty token only reaches here when the operator passed -allow-no-auth.
+func checkToken(w http.ResponseWriter, r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	presented := r.URL.Query().Get("token")
+	if presented == "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			presented = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+		return true
+	}
+	http.Error(w, "invalid token", http.StatusUnauthorized)
+	return false
+}
+
+// serveWS upgrades to a WebSocket and runs the action/event loop for a
+// single controller connection.
+func serveWS(w http.ResponseWriter, r *http.Request, cfg serverConfig, upgrader websocket.Upgrader) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("robotgo_executor: websocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	send := func(msg wsMessage) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.WriteJSON(msg)
+	}
+
+	feed := inputFeed.subscribe()
+	defer inputFeed.unsubscribe(feed)
+	go func() {
+		for msg := range feed {
+			send(msg)
+		}
+	}()
+
+	limiter := newRateLimiter(cfg.RateLimitPerS)
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		send(dispatchRemoteAction(msg, limiter))
+	}
+}
+
+// serveRTCOffer accepts an SDP offer over HTTP and answers it, wiring the
+// resulting data channel to the same action dispatch and input feed as the
+// WebSocket path. This gives a browser-based controller a sub-frame-latency
+// channel alongside the existing JSON action dispatch.
+func serveRTCOffer(w http.ResponseWriter, r *http.Request, cfg serverConfig) {
+	var offer webrtc.SessionDescription
+	if err := json.NewDecoder(r.Body).Decode(&offer); err != nil {
+		http.Error(w, fmt.Sprintf("invalid offer: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("peer connection: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Make sure the PeerConnection is released however the session ends,
+	// instead of only on a clean data-channel close.
+	pc.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		switch s {
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed, webrtc.PeerConnectionStateDisconnected:
+			pc.Close()
+		}
+	})
+
+	limiter := newRateLimiter(cfg.RateLimitPerS)
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		var feed chan wsMessage
+		send := func(msg wsMessage) {
+			data, err := json.Marshal(msg)
+			if err == nil {
+				dc.Send(data)
+			}
+		}
+		dc.OnOpen(func() {
+			feed = inputFeed.subscribe()
+			go func(feed chan wsMessage) {
+				for msg := range feed {
+					send(msg)
+				}
+			}(feed)
+		})
+		dc.OnClose(func() {
+			if feed != nil {
+				inputFeed.unsubscribe(feed)
+			}
+			pc.Close()
+		})
+		dc.OnMessage(func(raw webrtc.DataChannelMessage) {
+			var msg wsMessage
+			if err := json.Unmarshal(raw.Data, &msg); err != nil {
+				send(wsMessage{Type: "error", Error: fmt.Sprintf("parsing JSON: %v", err)})
+				return
+			}
+			send(dispatchRemoteAction(msg, limiter))
+		})
+	})
+
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		http.Error(w, fmt.Sprintf("set remote description: %v", err), http.StatusInternalServerError)
+		pc.Close()
+		return
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("create answer: %v", err), http.StatusInternalServerError)
+		pc.Close()
+		return
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		http.Error(w, fmt.Sprintf("set local description: %v", err), http.StatusInternalServerError)
+		pc.Close()
+		return
+	}
+	<-gatherComplete
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pc.LocalDescription())
+}
+
+// dispatchRemoteAction runs one inbound action message through the rate
+// limiter and the shared actionRegistry, returning the ack/error to send
+// back to the controller.
+func dispatchRemoteAction(msg wsMessage, limiter *rateLimiter) wsMessage {
+	if msg.Type != "action" || msg.Action == nil {
+		return wsMessage{Type: "error", Error: `expected {"type":"action","action":{...}}`}
+	}
+	if !limiter.Allow() {
+		return wsMessage{Type: "error", Error: "rate limit exceeded"}
+	}
+	result, err := executeAction(*msg.Action)
+	if err != nil {
+		return wsMessage{Type: "ack", Error: err.Error()}
+	}
+	return wsMessage{Type: "ack", Result: result}
+}
+
+// inputFeedHub runs a single process-global gohook listener and fans its
+// events out to every subscribed connection. robotn/gohook's Start/End/
+// Process operate on one global hook, not a per-caller handle, so handing
+// each connection its own hook.Start()/hook.End() pair would race -- one
+// connection's End() would silently kill every other connection's feed
+// (and any concurrent record/replay action's hook use). Subscribing here
+// instead means the hook is started at most once for the life of the
+// process.
+//
+// recordTrack/replayTrack subscribe via subscribeRaw instead of subscribe:
+// they need the full hook.Event (Rawcode, Keychar, Button, Rotation) to
+// reconstruct Actions, not the trimmed wsMessage shape remote controllers
+// get over the event feed.
+type inputFeedHub struct {
+	mu           sync.Mutex
+	started      bool
+	listeners    map[chan wsMessage]struct{}
+	rawListeners map[chan hook.Event]struct{}
+}
+
+var inputFeed = &inputFeedHub{
+	listeners:    make(map[chan wsMessage]struct{}),
+	rawListeners: make(map[chan hook.Event]struct{}),
+}
+
+func (h *inputFeedHub) subscribe() chan wsMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan wsMessage, 32)
+	h.listeners[ch] = struct{}{}
+	h.ensureStartedLocked()
+	return ch
+}
+
+func (h *inputFeedHub) unsubscribe(ch chan wsMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.listeners[ch]; ok {
+		delete(h.listeners, ch)
+		close(ch)
+	}
+}
+
+// subscribeRaw hands the caller the unmodified hook.Event stream, for
+// recordTrack/replayTrack to drive their own key/mouse state machines
+// against the one shared hook listener.
+func (h *inputFeedHub) subscribeRaw() chan hook.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan hook.Event, 64)
+	h.rawListeners[ch] = struct{}{}
+	h.ensureStartedLocked()
+	return ch
+}
+
+func (h *inputFeedHub) unsubscribeRaw(ch chan hook.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.rawListeners[ch]; ok {
+		delete(h.rawListeners, ch)
+		close(ch)
+	}
+}
+
+func (h *inputFeedHub) ensureStartedLocked() {
+	if !h.started {
+		h.started = true
+		go h.run()
+	}
+}
+
+func (h *inputFeedHub) run() {
+	s := hook.Start()
+	for ev := range s {
+		msg := wsMessage{Type: "event", Event: map[string]interface{}{
+			"kind": eventKindName(ev.Kind),
+			"x":    ev.X,
+			"y":    ev.Y,
+		}}
+		h.mu.Lock()
+		for ch := range h.listeners {
+			select {
+			case ch <- msg:
+			default:
+				// Drop the event for a slow subscriber rather than block
+				// (or stall) every other connection's feed.
+			}
+		}
+		for ch := range h.rawListeners {
+			select {
+			case ch <- ev:
+			default:
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+func eventKindName(kind uint8) string {
+	switch kind {
+	case hook.MouseMove:
+		return "mouseMove"
+	case hook.MouseDown:
+		return "mouseDown"
+	case hook.MouseUp:
+		return "mouseUp"
+	case hook.MouseDrag:
+		return "mouseDrag"
+	case hook.MouseWheel:
+		return "mouseWheel"
+	case hook.KeyDown:
+		return "keyDown"
+	case hook.KeyUp:
+		return "keyUp"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRateLimiterAllowsUpToBurstThenBlocks(t *testing.T) {
+	rl := newRateLimiter(5)
+	for i := 0; i < 5; i++ {
+		if !rl.Allow() {
+			t.Fatalf("expected request %d within the initial burst to be allowed", i)
+		}
+	}
+	if rl.Allow() {
+		t.Error("expected the request beyond the burst to be rate limited")
+	}
+}
+
+func TestRateLimiterDefaultsWhenNonPositive(t *testing.T) {
+	rl := newRateLimiter(0)
+	if rl.max != 50 {
+		t.Errorf("got max %v, want the default of 50", rl.max)
+	}
+}
+
+func TestAllowedOriginChecker(t *testing.T) {
+	check := allowedOriginChecker([]string{"https://allowed.example"})
+
+	allowedReq := &http.Request{Header: http.Header{"Origin": []string{"https://allowed.example"}}}
+	if !check(allowedReq) {
+		t.Error("expected an allow-listed origin to be accepted")
+	}
+
+	deniedReq := &http.Request{Header: http.Header{"Origin": []string{"https://evil.example"}}}
+	if check(deniedReq) {
+		t.Error("expected a non-allow-listed origin to be rejected")
+	}
+
+	noOriginReq := &http.Request{Header: http.Header{}}
+	if !check(noOriginReq) {
+		t.Error("expected a request with no Origin header (non-browser client) to be accepted")
+	}
+}